@@ -0,0 +1,15 @@
+package postgrescmd
+
+import "github.com/urfave/cli/v2"
+
+// Command returns the "database" command group: operations on NAIS-managed
+// Cloud SQL databases across all supported engines. "postgres" is kept as
+// an alias since this group covers more than PostgreSQL now.
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:        "database",
+		Aliases:     []string{"postgres"},
+		Usage:       "Manage NAIS-managed Cloud SQL databases",
+		Subcommands: []*cli.Command{passwordRotateCommand()},
+	}
+}
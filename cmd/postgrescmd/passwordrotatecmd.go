@@ -3,8 +3,8 @@ package postgrescmd
 import (
 	"fmt"
 
+	"github.com/nais/cli/pkg/engine"
 	"github.com/nais/cli/pkg/metrics"
-	"github.com/nais/cli/pkg/postgres"
 	"github.com/urfave/cli/v2"
 )
 
@@ -38,7 +38,7 @@ func passwordRotateCommand() *cli.Command {
 			namespace := context.String("namespace")
 			cluster := context.String("context")
 
-			return postgres.RotatePassword(context.Context, appName, cluster, namespace)
+			return engine.RotatePasswordForApp(context.Context, cluster, appName, namespace)
 		},
 	}
 }
@@ -0,0 +1,511 @@
+package postgres
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nais/cli/cmd"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	batch_v1 "k8s.io/api/batch/v1"
+	core_v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+const backupHelp = `Backup dumps a NAIS-managed Cloud SQL database without requiring
+pg_dump to be installed locally. With --bucket, a short-lived Kubernetes Job
+runs pg_dump and uploads the archive straight to GCS. Without --bucket, the
+dump is streamed to stdout the same way 'kubectl cp' streams a file: a pod is
+created, pg_dump is exec'd into it, and its output is piped to the caller.`
+
+// dumpJobImage must have pg_dump/pg_restore, psql and gsutil on PATH: the
+// first two do the actual dump/restore, psql backs --point-in-time, and
+// gsutil is used by the Job variant (--bucket) to talk to GCS.
+const dumpJobImage = "europe-north1-docker.pkg.dev/nais-io/nais/images/postgres-backup:latest"
+
+// BackupOptions controls what a backup dumps and where the archive ends up.
+type BackupOptions struct {
+	Bucket      string
+	SchemaOnly  bool
+	DataOnly    bool
+	Tables      []string
+	PointInTime bool
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup [app-name] [flags]",
+	Short: "Back up a postgres instance to a GCS bucket or stdout",
+	Long:  backupHelp,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(command *cobra.Command, args []string) error {
+		appName := args[0]
+		namespace := viper.GetString(cmd.NamespaceFlag)
+		context := viper.GetString(cmd.ContextFlag)
+
+		opts := BackupOptions{
+			Bucket:      viper.GetString(bucketFlag),
+			SchemaOnly:  viper.GetBool(schemaOnlyFlag),
+			DataOnly:    viper.GetBool(dataOnlyFlag),
+			Tables:      viper.GetStringSlice(tableFlag),
+			PointInTime: viper.GetBool(pointInTimeFlag),
+		}
+		if opts.SchemaOnly && opts.DataOnly {
+			return fmt.Errorf("--schema-only and --data-only are mutually exclusive")
+		}
+
+		dbInfo, err := NewDBInfo(appName, namespace, context)
+		if err != nil {
+			return err
+		}
+
+		ctx := command.Context()
+
+		if opts.Bucket != "" {
+			return runDumpJob(ctx, dbInfo, opts)
+		}
+		return execDump(ctx, dbInfo, opts, os.Stdout)
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore [app-name] [archive] [flags]",
+	Short: "Restore a postgres instance from a backup produced by 'nais postgres backup'",
+	Long: `Restore reads an archive produced by 'nais postgres backup' and runs
+pg_restore against the instance. With --bucket, archive names an object in
+the bucket and a Kubernetes Job downloads and restores it. Without --bucket,
+archive is a local file path (or "-" for stdin), and it is streamed into a
+pod the same way 'kubectl cp' streams a file onto a pod's stdin.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(command *cobra.Command, args []string) error {
+		appName := args[0]
+		archive := args[1]
+		namespace := viper.GetString(cmd.NamespaceFlag)
+		context := viper.GetString(cmd.ContextFlag)
+
+		opts := BackupOptions{
+			Bucket:     viper.GetString(bucketFlag),
+			SchemaOnly: viper.GetBool(schemaOnlyFlag),
+			DataOnly:   viper.GetBool(dataOnlyFlag),
+			Tables:     viper.GetStringSlice(tableFlag),
+		}
+		if opts.SchemaOnly && opts.DataOnly {
+			return fmt.Errorf("--schema-only and --data-only are mutually exclusive")
+		}
+
+		dbInfo, err := NewDBInfo(appName, namespace, context)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("This will overwrite data in the %q database. Are you sure you want to continue (y/N): ", appName)
+		if !confirmed() {
+			return fmt.Errorf("cancelled by user")
+		}
+
+		ctx := command.Context()
+
+		if opts.Bucket != "" {
+			return runRestoreJob(ctx, dbInfo, archive, opts)
+		}
+
+		var in io.Reader = os.Stdin
+		if archive != "-" {
+			f, err := os.Open(archive)
+			if err != nil {
+				return fmt.Errorf("failed to open archive %s: %w", archive, err)
+			}
+			defer f.Close()
+			in = f
+		}
+		return execRestore(ctx, dbInfo, opts, in)
+	},
+}
+
+func init() {
+	backupCmd.Flags().String(bucketFlag, "", "GCS bucket to upload the archive to. If unset, the archive is streamed to stdout.")
+	backupCmd.Flags().Bool(schemaOnlyFlag, false, "Only dump the schema, no data.")
+	backupCmd.Flags().Bool(dataOnlyFlag, false, "Only dump data, no schema.")
+	backupCmd.Flags().StringSlice(tableFlag, nil, "Limit the dump to these tables. Repeatable.")
+	backupCmd.Flags().Bool(pointInTimeFlag, false, "Print the current WAL LSN at the start of the dump, for point-in-time recovery.")
+	_ = viper.BindPFlags(backupCmd.Flags())
+
+	restoreCmd.Flags().String(bucketFlag, "", "GCS bucket to download the archive from. If unset, archive is a local file path (or - for stdin).")
+	restoreCmd.Flags().Bool(schemaOnlyFlag, false, "Only restore the schema, no data.")
+	restoreCmd.Flags().Bool(dataOnlyFlag, false, "Only restore data, no schema.")
+	restoreCmd.Flags().StringSlice(tableFlag, nil, "Limit the restore to these tables. Repeatable.")
+	_ = viper.BindPFlags(restoreCmd.Flags())
+}
+
+const (
+	bucketFlag      = "bucket"
+	schemaOnlyFlag  = "schema-only"
+	dataOnlyFlag    = "data-only"
+	tableFlag       = "table"
+	pointInTimeFlag = "point-in-time"
+)
+
+func dumpFilterArgs(opts BackupOptions) []string {
+	var args []string
+	if opts.SchemaOnly {
+		args = append(args, "--schema-only")
+	}
+	if opts.DataOnly {
+		args = append(args, "--data-only")
+	}
+	for _, table := range opts.Tables {
+		args = append(args, "--table", table)
+	}
+	return args
+}
+
+// runDumpJob creates a Job that pipes pg_dump straight into gsutil, uploading
+// the archive to opts.Bucket without ever holding the whole dump in memory.
+func runDumpJob(ctx context.Context, dbInfo *DBInfo, opts BackupOptions) error {
+	client, err := kubernetesClientFor(dbInfo.Context)
+	if err != nil {
+		return err
+	}
+
+	objectName := fmt.Sprintf("%s-%s.dump", dbInfo.AppName, strings.ToLower(rand.String(8)))
+	dumpArgs := append([]string{"pg_dump", "-Fc", "--no-owner"}, dumpFilterArgs(opts)...)
+
+	script := fmt.Sprintf("%s | gsutil cp - %s", strings.Join(dumpArgs, " "), gcsURL(opts.Bucket, objectName))
+	if opts.PointInTime {
+		script = fmt.Sprintf(`echo "point-in-time WAL LSN: $(psql -Atc 'select pg_current_wal_lsn()')" && %s`, script)
+	}
+
+	job := archiveJob(dbInfo, "backup", []string{"sh", "-c", script})
+	if err := runArchiveJob(ctx, client, job, os.Stdout); err != nil {
+		return err
+	}
+
+	pterm.Success.Printf("Archive uploaded to %s\n", gcsURL(opts.Bucket, objectName))
+	return nil
+}
+
+// runRestoreJob creates a Job that downloads archive from opts.Bucket and
+// pipes it straight into pg_restore.
+func runRestoreJob(ctx context.Context, dbInfo *DBInfo, archive string, opts BackupOptions) error {
+	client, err := kubernetesClientFor(dbInfo.Context)
+	if err != nil {
+		return err
+	}
+
+	restoreArgs := append([]string{"pg_restore", "--no-owner", "--clean", "--if-exists"}, dumpFilterArgs(opts)...)
+	script := fmt.Sprintf("gsutil cp %s - | %s", gcsURL(opts.Bucket, archive), strings.Join(restoreArgs, " "))
+
+	job := archiveJob(dbInfo, "restore", []string{"sh", "-c", script})
+	return runArchiveJob(ctx, client, job, os.Stdout)
+}
+
+func gcsURL(bucket, object string) string {
+	return fmt.Sprintf("gs://%s/%s", bucket, object)
+}
+
+func archiveJob(dbInfo *DBInfo, action string, command []string) *batch_v1.Job {
+	jobName := fmt.Sprintf("%s-%s-%s", dbInfo.AppName, action, strings.ToLower(rand.String(5)))
+	backoffLimit := int32(0)
+
+	return &batch_v1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName,
+			Namespace: dbInfo.Namespace,
+			Labels: map[string]string{
+				"app":                      dbInfo.AppName,
+				"migrator.nais.io/purpose": action,
+			},
+		},
+		Spec: batch_v1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: core_v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"app": dbInfo.AppName},
+				},
+				Spec: core_v1.PodSpec{
+					RestartPolicy: core_v1.RestartPolicyNever,
+					Containers: []core_v1.Container{
+						{
+							Name:    action,
+							Image:   dumpJobImage,
+							Command: command,
+							EnvFrom: []core_v1.EnvFromSource{
+								{SecretRef: &core_v1.SecretEnvSource{
+									LocalObjectReference: core_v1.LocalObjectReference{Name: dbInfo.SecretName},
+								}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// runArchiveJob creates job in its namespace, streams its pod's logs to out
+// as soon as it starts running, and waits for it to finish.
+func runArchiveJob(ctx context.Context, client kubernetes.Interface, job *batch_v1.Job, out io.Writer) error {
+	pterm.Info.Printf("Starting %s job %s in namespace %s\n", job.Labels["migrator.nais.io/purpose"], job.Name, job.Namespace)
+	created, err := client.BatchV1().Jobs(job.Namespace).Create(ctx, job, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create job: %w", err)
+	}
+
+	return waitForJob(ctx, client, created, out)
+}
+
+// waitForJob polls job until it completes or fails, streaming its pod's logs
+// to out in the background once the pod has left the Pending phase.
+func waitForJob(ctx context.Context, client kubernetes.Interface, job *batch_v1.Job, out io.Writer) error {
+	streaming := false
+
+	for {
+		if !streaming {
+			if podName, ok := jobPodName(ctx, client, job); ok {
+				streaming = true
+				go streamPodLogs(ctx, client, job.Namespace, podName, out)
+			}
+		}
+
+		current, err := client.BatchV1().Jobs(job.Namespace).Get(ctx, job.Name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get job status: %w", err)
+		}
+
+		if current.Status.Succeeded > 0 {
+			pterm.Success.Printf("Job %s completed\n", job.Name)
+			return nil
+		}
+		if current.Status.Failed > 0 {
+			return fmt.Errorf("job %s failed, check logs with 'kubectl logs -n %s -l job-name=%s'", job.Name, job.Namespace, job.Name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func jobPodName(ctx context.Context, client kubernetes.Interface, job *batch_v1.Job) (string, bool) {
+	pods, err := client.CoreV1().Pods(job.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("job-name=%s", job.Name),
+	})
+	if err != nil || len(pods.Items) == 0 {
+		return "", false
+	}
+	// A Pending pod has no started container whose logs can be followed yet.
+	if pods.Items[0].Status.Phase == core_v1.PodPending {
+		return "", false
+	}
+	return pods.Items[0].Name, true
+}
+
+// streamPodLogs follows podName's logs and copies them to out until the pod
+// stops logging or ctx is cancelled. Errors are reported rather than
+// returned, since log streaming is best-effort alongside waitForJob's own
+// polling of the job's terminal status.
+func streamPodLogs(ctx context.Context, client kubernetes.Interface, namespace, podName string, out io.Writer) {
+	stream, err := client.CoreV1().Pods(namespace).GetLogs(podName, &core_v1.PodLogOptions{Follow: true}).Stream(ctx)
+	if err != nil {
+		pterm.Warning.Printf("failed to stream logs from %s: %v\n", podName, err)
+		return
+	}
+	defer stream.Close()
+
+	if _, err := io.Copy(out, stream); err != nil && ctx.Err() == nil {
+		pterm.Warning.Printf("log stream from %s ended: %v\n", podName, err)
+	}
+}
+
+// execDump runs pg_dump directly against a short-lived pod and streams its
+// output to out, the same way `kubectl cp` streams a file off a pod, for the
+// case where there's no bucket to upload to.
+func execDump(ctx context.Context, dbInfo *DBInfo, opts BackupOptions, out io.Writer) error {
+	client, restConfig, err := kubernetesClientAndConfigFor(dbInfo.Context)
+	if err != nil {
+		return err
+	}
+
+	podName, cleanup, err := createTunnelPod(ctx, client, dbInfo)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if opts.PointInTime {
+		lsn, err := execCaptureOutput(ctx, client, restConfig, dbInfo.Namespace, podName, []string{"psql", "-Atc", "select pg_current_wal_lsn()"})
+		if err != nil {
+			return fmt.Errorf("failed to record point-in-time WAL LSN: %w", err)
+		}
+		pterm.Info.Printf("Point-in-time WAL LSN: %s\n", strings.TrimSpace(lsn))
+	}
+
+	args := append([]string{"pg_dump", "-Fc", "--no-owner"}, dumpFilterArgs(opts)...)
+	return execStream(ctx, client, restConfig, dbInfo.Namespace, podName, args, nil, out)
+}
+
+// execRestore streams in into a short-lived pod's pg_restore stdin, the same
+// way `kubectl cp` streams a file onto a pod, for the case where there's no
+// bucket to download from.
+func execRestore(ctx context.Context, dbInfo *DBInfo, opts BackupOptions, in io.Reader) error {
+	client, restConfig, err := kubernetesClientAndConfigFor(dbInfo.Context)
+	if err != nil {
+		return err
+	}
+
+	podName, cleanup, err := createTunnelPod(ctx, client, dbInfo)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	args := append([]string{"pg_restore", "--no-owner", "--clean", "--if-exists"}, dumpFilterArgs(opts)...)
+	return execStream(ctx, client, restConfig, dbInfo.Namespace, podName, args, in, os.Stdout)
+}
+
+// createTunnelPod creates a pod running `sleep infinity` in dumpJobImage,
+// idling until something is exec'd into it, and waits for it to start
+// running. The returned cleanup func deletes the pod; callers must defer it.
+func createTunnelPod(ctx context.Context, client kubernetes.Interface, dbInfo *DBInfo) (string, func(), error) {
+	podName := fmt.Sprintf("%s-db-tunnel-%s", dbInfo.AppName, strings.ToLower(rand.String(5)))
+
+	pod := &core_v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      podName,
+			Namespace: dbInfo.Namespace,
+			Labels: map[string]string{
+				"app":                      dbInfo.AppName,
+				"migrator.nais.io/purpose": "db-tunnel",
+			},
+		},
+		Spec: core_v1.PodSpec{
+			RestartPolicy: core_v1.RestartPolicyNever,
+			Containers: []core_v1.Container{
+				{
+					Name:    "db-tunnel",
+					Image:   dumpJobImage,
+					Command: []string{"sleep", "infinity"},
+					EnvFrom: []core_v1.EnvFromSource{
+						{SecretRef: &core_v1.SecretEnvSource{
+							LocalObjectReference: core_v1.LocalObjectReference{Name: dbInfo.SecretName},
+						}},
+					},
+				},
+			},
+		},
+	}
+
+	if _, err := client.CoreV1().Pods(dbInfo.Namespace).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		return "", nil, fmt.Errorf("failed to create tunnel pod %s: %w", podName, err)
+	}
+
+	cleanup := func() {
+		gracePeriod := int64(0)
+		if err := client.CoreV1().Pods(dbInfo.Namespace).Delete(context.Background(), podName, metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod}); err != nil {
+			pterm.Warning.Printf("failed to clean up tunnel pod %s: %v\n", podName, err)
+		}
+	}
+
+	if err := waitForTunnelPodRunning(ctx, client, dbInfo.Namespace, podName); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return podName, cleanup, nil
+}
+
+func waitForTunnelPodRunning(ctx context.Context, client kubernetes.Interface, namespace, podName string) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	for {
+		pod, err := client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get tunnel pod %s: %w", podName, err)
+		}
+		if pod.Status.Phase == core_v1.PodRunning {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("tunnel pod %s did not start running in time", podName)
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// execStream runs command inside podName via the exec subresource, wiring
+// stdin/stdout to the given reader/writer.
+func execStream(ctx context.Context, client kubernetes.Interface, restConfig *rest.Config, namespace, podName string, command []string, stdin io.Reader, stdout io.Writer) error {
+	req := client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(podName).
+		SubResource("exec")
+	req.VersionedParams(&core_v1.PodExecOptions{
+		Command: command,
+		Stdin:   stdin != nil,
+		Stdout:  stdout != nil,
+		Stderr:  true,
+	}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(restConfig, "POST", req.URL())
+	if err != nil {
+		return fmt.Errorf("failed to create executor: %w", err)
+	}
+
+	return executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: os.Stderr,
+	})
+}
+
+func execCaptureOutput(ctx context.Context, client kubernetes.Interface, restConfig *rest.Config, namespace, podName string, command []string) (string, error) {
+	var out bytes.Buffer
+	if err := execStream(ctx, client, restConfig, namespace, podName, command, nil, &out); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func kubernetesClientFor(contextName string) (kubernetes.Interface, error) {
+	client, _, err := kubernetesClientAndConfigFor(contextName)
+	return client, err
+}
+
+func kubernetesClientAndConfigFor(contextName string) (kubernetes.Interface, *rest.Config, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: contextName}
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load kube config: %w", err)
+	}
+
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	return client, restConfig, nil
+}
+
+func confirmed() bool {
+	input := bufio.NewScanner(os.Stdin)
+	input.Scan()
+	return strings.EqualFold(strings.TrimSpace(input.Text()), "y")
+}
@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDumpFilterArgs(t *testing.T) {
+	tests := map[string]struct {
+		opts BackupOptions
+		want []string
+	}{
+		"no filters": {
+			opts: BackupOptions{},
+			want: nil,
+		},
+		"schema only": {
+			opts: BackupOptions{SchemaOnly: true},
+			want: []string{"--schema-only"},
+		},
+		"data only": {
+			opts: BackupOptions{DataOnly: true},
+			want: []string{"--data-only"},
+		},
+		"tables are repeated as --table flags": {
+			opts: BackupOptions{Tables: []string{"foo", "bar"}},
+			want: []string{"--table", "foo", "--table", "bar"},
+		},
+		"schema-only and tables combine": {
+			opts: BackupOptions{SchemaOnly: true, Tables: []string{"foo"}},
+			want: []string{"--schema-only", "--table", "foo"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := dumpFilterArgs(tt.opts)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGcsURL(t *testing.T) {
+	tests := map[string]struct {
+		bucket string
+		object string
+		want   string
+	}{
+		"joins bucket and object": {
+			bucket: "my-bucket",
+			object: "app-abcd1234.dump",
+			want:   "gs://my-bucket/app-abcd1234.dump",
+		},
+		"object with a path prefix": {
+			bucket: "my-bucket",
+			object: "backups/app.dump",
+			want:   "gs://my-bucket/backups/app.dump",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := gcsURL(tt.bucket, tt.object); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
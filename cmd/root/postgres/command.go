@@ -0,0 +1,18 @@
+package postgres
+
+import "github.com/spf13/cobra"
+
+// NewCommand returns the "database" command group: prepare, backup and
+// restore for NAIS-managed Cloud SQL instances. prepare is dispatched across
+// PostgreSQL, MySQL and MSSQL via the engine package; backup and restore run
+// pg_dump/pg_restore and so only handle PostgreSQL. "postgres" is kept as
+// an alias since this group covers more than PostgreSQL now.
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "database",
+		Aliases: []string{"postgres"},
+		Short:   "Manage NAIS-managed Cloud SQL databases",
+	}
+	cmd.AddCommand(prepareCmd, backupCmd, restoreCmd)
+	return cmd
+}
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"bufio"
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -9,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/nais/cli/cmd"
+	"github.com/nais/cli/pkg/engine"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	corev1 "k8s.io/api/core/v1"
@@ -16,45 +18,53 @@ import (
 	_ "github.com/GoogleCloudPlatform/cloudsql-proxy/proxy/dialers/postgres"
 )
 
-const prepareHelp = `Prepare will prepare the postgres instance by connecting using the
+const prepareHelp = `Prepare will prepare the database instance by connecting using the
 application credentials and modify the permissions on the public schema.
 All IAM users in your GCP project will be able to connect to the instance.
 
-This operation is only required to run once for each postgresql instance.`
+This operation is only required to run once for each database instance.`
 
 const prepareHelp2 = ``
 
-var ddlStatements = []string{
-	"alter default privileges in schema public grant CHANGEME on tables to cloudsqliamuser;",
-	"alter default privileges in schema public grant CHANGEME on sequences to cloudsqliamuser;",
-	"grant CHANGEME on all tables in schema public to cloudsqliamuser;",
-	"grant CHANGEME on all sequences in schema public to cloudsqliamuser;",
-}
+var grantFlagValues []string
+var dryRun bool
 
 var prepareCmd = &cobra.Command{
 	Use:   "prepare [app-name] [flags]",
-	Short: "Prepare your postgres instance for use with personal accounts",
+	Short: "Prepare your database instance for use with personal accounts",
 	Long:  prepareHelp,
 	Args:  cobra.ExactArgs(1),
 	RunE: func(command *cobra.Command, args []string) error {
 		appName := args[0]
 		namespace := viper.GetString(cmd.NamespaceFlag)
 		context := viper.GetString(cmd.ContextFlag)
-		allPrivs := viper.GetBool(cmd.AllPrivs)
+
+		grantSpecs, err := resolveGrantSpecs(grantFlagValues)
+		if err != nil {
+			return err
+		}
+
 		dbInfo, err := NewDBInfo(appName, namespace, context)
 		if err != nil {
 			return err
 		}
 
+		eng, err := engine.Detect(dbInfo.SQLInstanceType)
+		if err != nil {
+			return err
+		}
+
 		ctx := command.Context()
 
 		fmt.Println(prepareHelp)
 
-		fmt.Print("\nAre you sure you want to continue (y/N): ")
-		input := bufio.NewScanner(os.Stdin)
-		input.Scan()
-		if !strings.EqualFold(strings.TrimSpace(input.Text()), "y") {
-			return fmt.Errorf("cancelled by user")
+		if !dryRun {
+			fmt.Print("\nAre you sure you want to continue (y/N): ")
+			input := bufio.NewScanner(os.Stdin)
+			input.Scan()
+			if !strings.EqualFold(strings.TrimSpace(input.Text()), "y") {
+				return fmt.Errorf("cancelled by user")
+			}
 		}
 
 		connectionInfo, err := dbInfo.DBConnection(ctx)
@@ -62,21 +72,98 @@ var prepareCmd = &cobra.Command{
 			return err
 		}
 
-		db, err := sql.Open("cloudsqlpostgres", connectionInfo.ConnectionString())
+		db, err := sql.Open(eng.Driver(), connectionInfo.ConnectionString())
 		if err != nil {
 			log.Fatal(err)
 		}
 		defer db.Close()
 
-		for _, ddl := range ddlStatements {
-			_, err = db.ExecContext(ctx, setGrant(ddl, allPrivs))
+		return applyGrants(ctx, db, eng, grantSpecs, dryRun)
+	},
+}
+
+func init() {
+	prepareCmd.Flags().StringArrayVar(&grantFlagValues, "grant", nil,
+		"Grant specification, e.g. schema=public,privs=SELECT,objects=TABLES,to=cloudsqliamuser. Repeatable. Defaults to SELECT on tables and sequences in public for cloudsqliamuser.")
+	prepareCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the statements that would be run without executing them.")
+}
+
+func resolveGrantSpecs(flagValues []string) ([]engine.GrantSpec, error) {
+	if len(flagValues) == 0 {
+		return engine.DefaultGrantSpecs(), nil
+	}
+
+	specs := make([]engine.GrantSpec, 0, len(flagValues))
+	for _, value := range flagValues {
+		spec, err := engine.ParseGrantFlag(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --grant %q: %w", value, err)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// idempotentPreparer is implemented by engines that can check which grants
+// already hold (currently only PostgreSQL, via information_schema) so
+// applyGrants can skip statements that wouldn't change anything.
+type idempotentPreparer interface {
+	PrepareStatementsWithTx(ctx context.Context, tx *sql.Tx, spec engine.GrantSpec) ([]string, error)
+}
+
+// batchPreparer is implemented by engines whose statements must run as a
+// single sqlcmd-style batch script rather than one at a time (currently only
+// MSSQL, via engine.ExecBatches).
+type batchPreparer interface {
+	ExecBatch(ctx context.Context, tx *sql.Tx, stmts []string) error
+}
+
+// applyGrants runs every statement eng produces for specs inside a single
+// transaction so a partial failure rolls back.
+func applyGrants(ctx context.Context, db *sql.DB, eng engine.Engine, specs []engine.GrantSpec, dryRun bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var stmts []string
+	for _, spec := range specs {
+		if preparer, ok := eng.(idempotentPreparer); ok {
+			specStmts, err := preparer.PrepareStatementsWithTx(ctx, tx, spec)
 			if err != nil {
-				log.Fatal(err)
+				return fmt.Errorf("failed to check existing grants: %w", err)
 			}
+			stmts = append(stmts, specStmts...)
+		} else {
+			stmts = append(stmts, eng.PrepareStatements(spec)...)
 		}
+	}
 
+	if dryRun {
+		fmt.Println("-- dry run: the following statements would be executed --")
+		fmt.Println("BEGIN;")
+		for _, stmt := range stmts {
+			fmt.Println(stmt)
+		}
+		fmt.Println("COMMIT;")
 		return nil
-	},
+	}
+
+	if batcher, ok := eng.(batchPreparer); ok {
+		if err := batcher.ExecBatch(ctx, tx, stmts); err != nil {
+			return fmt.Errorf("failed to execute batch: %w", err)
+		}
+		return tx.Commit()
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to execute %q: %w", stmt, err)
+		}
+	}
+
+	return tx.Commit()
 }
 
 func getSecretDataValue(secret *corev1.Secret, suffix string) string {
@@ -87,11 +174,3 @@ func getSecretDataValue(secret *corev1.Secret, suffix string) string {
 	}
 	return ""
 }
-
-func setGrant(sql string, allPrivs bool) string {
-	sqlGrant := "SELECT"
-	if allPrivs {
-		sqlGrant = "ALL"
-	}
-	return strings.Replace(sql, "CHANGEME", sqlGrant, 1)
-}
@@ -3,17 +3,26 @@ package debug
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
-	"strings"
 	"time"
 
 	"github.com/pterm/pterm"
-	k8serrors "k8s.io/apimachinery/pkg/api/errors"
-
 	core_v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/remotecommand"
+	watchtools "k8s.io/client-go/tools/watch"
+	"k8s.io/client-go/util/term"
 )
 
 const (
@@ -21,10 +30,24 @@ const (
 	debuggerContainerDefaultName = "debugger"
 )
 
+// Profile mirrors the semantics of `kubectl debug --profile`: it controls the
+// SecurityContext and Capabilities granted to the ephemeral debug container.
+type Profile string
+
+const (
+	ProfileLegacy     Profile = "legacy"
+	ProfileGeneral    Profile = "general"
+	ProfileBaseline   Profile = "baseline"
+	ProfileRestricted Profile = "restricted"
+	ProfileNetadmin   Profile = "netadmin"
+	ProfileSysadmin   Profile = "sysadmin"
+)
+
 type Debug struct {
-	ctx    context.Context
-	client kubernetes.Interface
-	cfg    *Config
+	ctx        context.Context
+	client     kubernetes.Interface
+	restConfig *rest.Config
+	cfg        *Config
 }
 
 type Config struct {
@@ -34,13 +57,25 @@ type Config struct {
 	DebugImage   string
 	CopyPod      bool
 	ByPod        bool
+	Profile      Profile
+
+	// Record, if set, is the path an asciicast v2 recording of the session
+	// is written to.
+	Record string
+	// RecordRedact overrides the default secret-redaction pattern applied
+	// to recorded output before it's written to Record.
+	RecordRedact string
 }
 
-func Setup(client kubernetes.Interface, cfg *Config) *Debug {
+func Setup(client kubernetes.Interface, restConfig *rest.Config, cfg *Config) *Debug {
+	if cfg.Profile == "" {
+		cfg.Profile = ProfileRestricted
+	}
 	return &Debug{
-		ctx:    context.Background(),
-		client: client,
-		cfg:    cfg,
+		ctx:        context.Background(),
+		client:     client,
+		restConfig: restConfig,
+		cfg:        cfg,
 	}
 }
 
@@ -66,6 +101,59 @@ func debuggerContainerName(podName string) string {
 	return fmt.Sprintf("%s-%s", podName, debuggerSuffix)
 }
 
+// securityContextForProfile returns the SecurityContext to apply to the debug
+// container for the given profile, following the privilege levels documented
+// for `kubectl debug --profile`.
+func securityContextForProfile(profile Profile) *core_v1.SecurityContext {
+	truthy := true
+	falsy := false
+
+	switch profile {
+	case ProfileLegacy:
+		return &core_v1.SecurityContext{}
+	case ProfileGeneral:
+		return &core_v1.SecurityContext{
+			AllowPrivilegeEscalation: &falsy,
+			Capabilities: &core_v1.Capabilities{
+				Drop: []core_v1.Capability{"ALL"},
+			},
+		}
+	case ProfileBaseline:
+		return &core_v1.SecurityContext{
+			AllowPrivilegeEscalation: &falsy,
+			RunAsNonRoot:             &truthy,
+			Capabilities: &core_v1.Capabilities{
+				Drop: []core_v1.Capability{"ALL"},
+			},
+		}
+	case ProfileNetadmin:
+		return &core_v1.SecurityContext{
+			AllowPrivilegeEscalation: &falsy,
+			Capabilities: &core_v1.Capabilities{
+				Add:  []core_v1.Capability{"NET_ADMIN", "NET_RAW"},
+				Drop: []core_v1.Capability{"ALL"},
+			},
+		}
+	case ProfileSysadmin:
+		return &core_v1.SecurityContext{
+			Privileged: &truthy,
+		}
+	case ProfileRestricted:
+		fallthrough
+	default:
+		return &core_v1.SecurityContext{
+			AllowPrivilegeEscalation: &falsy,
+			RunAsNonRoot:             &truthy,
+			Capabilities: &core_v1.Capabilities{
+				Drop: []core_v1.Capability{"ALL"},
+			},
+			SeccompProfile: &core_v1.SeccompProfile{
+				Type: core_v1.SeccompProfileTypeRuntimeDefault,
+			},
+		}
+	}
+}
+
 func (d *Debug) debugPod(podName string) error {
 	const maxRetries = 6
 	const pollInterval = 5
@@ -113,93 +201,234 @@ func (d *Debug) debugPod(podName string) error {
 	return d.createDebugPod(podName)
 }
 
+// attachToExistingDebugContainer opens a SPDY attach stream to a debug
+// container that is already running, attaching the current terminal's
+// stdin/stdout/stderr to it.
 func (d *Debug) attachToExistingDebugContainer(podName string) error {
-	cmd := exec.Command(
-		"kubectl",
-		"attach",
-		"-n", d.cfg.Namespace,
-		fmt.Sprintf("pod/%s", podName),
-		"-c", debuggerContainerDefaultName,
-		"-i",
-		"-t",
-	)
+	pterm.Success.Printf("Attached to pod %s\n", podName)
+	return d.attachStream(podName, debuggerContainerDefaultName)
+}
+
+// createDebugPod creates the ephemeral debug container (or, when CopyPod is
+// set, a copy of the target pod with the debug container added) and waits
+// for it to start running before attaching.
+func (d *Debug) createDebugPod(podName string) error {
+	if d.cfg.CopyPod {
+		copyName := debuggerContainerName(podName)
+		pod, err := d.client.CoreV1().Pods(d.cfg.Namespace).Get(d.ctx, podName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get pod %s: %v", podName, err)
+		}
+
+		copyPod := buildPodCopy(pod, copyName, d.cfg.DebugImage, d.cfg.Profile)
+		if _, err := d.client.CoreV1().Pods(d.cfg.Namespace).Create(d.ctx, copyPod, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create debug pod copy %s: %v", copyName, err)
+		}
+
+		pterm.Info.Printf("Debugging pod copy created, enable process namespace sharing in %s\n", copyName)
+		pterm.Info.Printf("Using debugger image %s\n", d.cfg.DebugImage)
+
+		if err := d.waitForContainerRunning(copyName, debuggerContainerDefaultName); err != nil {
+			return fmt.Errorf("debug container did not start: %w", err)
+		}
 
-	if d.cfg.Context != "" {
-		cmd.Args = append(cmd.Args, "--context", d.cfg.Context)
+		return d.attachToExistingDebugContainer(copyName)
 	}
 
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	ec := buildEphemeralContainer(d.cfg.DebugImage, d.cfg.Profile)
+	pod, err := d.client.CoreV1().Pods(d.cfg.Namespace).Get(d.ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get pod %s: %v", podName, err)
+	}
 
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start attach command: %v", err)
+	podJS, err := runtime.Encode(scheme.Codecs.LegacyCodec(core_v1.SchemeGroupVersion), pod)
+	if err != nil {
+		return fmt.Errorf("failed to encode existing pod: %w", err)
 	}
-	pterm.Success.Printf("Attached to pod %s\n", podName)
 
-	if err := cmd.Wait(); err != nil {
-		return fmt.Errorf("attach command failed: %v", err)
+	withDebug := pod.DeepCopy()
+	withDebug.Spec.EphemeralContainers = append(withDebug.Spec.EphemeralContainers, *ec)
+	debugJS, err := runtime.Encode(scheme.Codecs.LegacyCodec(core_v1.SchemeGroupVersion), withDebug)
+	if err != nil {
+		return fmt.Errorf("failed to encode debug pod: %w", err)
 	}
 
-	return nil
-}
+	patch, err := strategicpatch.CreateTwoWayMergePatch(podJS, debugJS, pod)
+	if err != nil {
+		return fmt.Errorf("failed to create patch: %w", err)
+	}
 
-func (d *Debug) createDebugPod(podName string) error {
-	args := []string{
-		"debug",
-		"-n", d.cfg.Namespace,
-		fmt.Sprintf("pod/%s", podName),
-		"-it",
-		"--stdin",
-		"--tty",
-		"--profile=restricted",
-		"-q",
-		"--image", d.cfg.DebugImage,
+	if _, err := d.client.CoreV1().Pods(d.cfg.Namespace).Patch(d.ctx, podName, types.StrategicMergePatchType, patch, metav1.PatchOptions{}, "ephemeralcontainers"); err != nil {
+		return fmt.Errorf("failed to patch ephemeral containers onto %s: %w", podName, err)
 	}
 
-	if d.cfg.Context != "" {
-		args = append(args, "--context", d.cfg.Context)
+	pterm.Info.Println("Debugging container created...")
+	pterm.Info.Printf("Using debugger image %s\n", d.cfg.DebugImage)
+
+	if err := d.waitForContainerRunning(podName, ec.Name); err != nil {
+		return fmt.Errorf("debug container did not start: %w", err)
 	}
 
-	if d.cfg.CopyPod {
-		args = append(args,
-			"--copy-to", debuggerContainerName(podName),
-			"-c", "debugger",
-		)
-	} else {
-		args = append(args,
-			"--target", d.cfg.WorkloadName)
+	return d.attachStream(podName, ec.Name)
+}
+
+// waitForContainerRunning blocks until the named container in podName
+// transitions to the Running state, using a field-selector scoped watch
+// rather than polling.
+func (d *Debug) waitForContainerRunning(podName, containerName string) error {
+	ctx, cancel := context.WithTimeout(d.ctx, 2*time.Minute)
+	defer cancel()
+
+	fieldSelector := fields.OneTermEqualSelector("metadata.name", podName).String()
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = fieldSelector
+			return d.client.CoreV1().Pods(d.cfg.Namespace).List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = fieldSelector
+			return d.client.CoreV1().Pods(d.cfg.Namespace).Watch(ctx, options)
+		},
 	}
 
-	cmd := exec.Command("kubectl", args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	_, err := watchtools.UntilWithSync(ctx, lw, &core_v1.Pod{}, nil, func(event watch.Event) (bool, error) {
+		pod, ok := event.Object.(*core_v1.Pod)
+		if !ok {
+			return false, nil
+		}
+		for _, c := range pod.Status.EphemeralContainerStatuses {
+			if c.Name == containerName {
+				if c.State.Running != nil {
+					return true, nil
+				}
+				if c.State.Terminated != nil {
+					return false, fmt.Errorf("debug container terminated: %s", c.State.Terminated.Reason)
+				}
+			}
+		}
+		for _, c := range pod.Status.ContainerStatuses {
+			if c.Name == containerName && c.State.Running != nil {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	return err
+}
 
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start debug command: %v", err)
+// attachStream opens a SPDY attach stream to containerName's own running
+// process in podName and wires it up to a raw terminal. It mirrors `kubectl
+// attach` rather than `kubectl exec`: it attaches to the process the
+// container was started with instead of spawning a new one, so it works
+// against minimal/distroless debug images that don't have a shell on PATH.
+func (d *Debug) attachStream(podName, containerName string) error {
+	req := d.client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(d.cfg.Namespace).
+		Name(podName).
+		SubResource("attach")
+	req.VersionedParams(&core_v1.PodAttachOptions{
+		Container: containerName,
+		Stdin:     true,
+		Stdout:    true,
+		Stderr:    true,
+		TTY:       true,
+	}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(d.restConfig, "POST", req.URL())
+	if err != nil {
+		return fmt.Errorf("failed to create executor: %w", err)
 	}
 
-	if d.cfg.CopyPod {
-		pterm.Info.Printf("Debugging pod copy created, enable process namespace sharing in %s\n", debuggerContainerName(podName))
-	} else {
-		pterm.Info.Println("Debugging container created...")
+	t := term.TTY{
+		In:  os.Stdin,
+		Out: os.Stdout,
+		Raw: true,
 	}
-	pterm.Info.Printf("Using debugger image %s\n", d.cfg.DebugImage)
 
-	if err := cmd.Wait(); err != nil {
-		if strings.Contains(err.Error(), "exit status 1") {
-			pterm.Info.Println("Debugging container exited")
-			return nil
+	var stdout, stderr io.Writer = os.Stdout, os.Stderr
+
+	if d.cfg.Record != "" {
+		size := t.GetSize()
+		width, height := 80, 24
+		if size != nil {
+			width, height = int(size.Width), int(size.Height)
 		}
-		return fmt.Errorf("debug command failed: %v", err)
+
+		rec, err := NewRecorder(d.cfg.Record, width, height, d.cfg.RecordRedact)
+		if err != nil {
+			return err
+		}
+		defer rec.Close()
+
+		stop := make(chan struct{})
+		defer close(stop)
+
+		stdout = recordingWriter{Writer: stdout, rec: rec}
+		stderr = recordingWriter{Writer: stderr, rec: rec}
+		pterm.Info.Printf("Recording session to %s\n", d.cfg.Record)
+
+		go watchResizes(rec, func() (uint16, uint16, bool) {
+			size := t.GetSize()
+			if size == nil {
+				return 0, 0, false
+			}
+			return size.Width, size.Height, true
+		}, stop)
 	}
 
-	if d.cfg.CopyPod {
-		pterm.Info.Printf("Run 'nais debug -cp %s' command to attach to the debug pod\n", d.cfg.WorkloadName)
+	return t.Safe(func() error {
+		return executor.StreamWithContext(d.ctx, remotecommand.StreamOptions{
+			Stdin:             os.Stdin,
+			Stdout:            stdout,
+			Stderr:            stderr,
+			Tty:               true,
+			TerminalSizeQueue: t.MonitorSize(t.GetSize()),
+		})
+	})
+}
+
+func buildEphemeralContainer(image string, profile Profile) *core_v1.EphemeralContainer {
+	return &core_v1.EphemeralContainer{
+		EphemeralContainerCommon: core_v1.EphemeralContainerCommon{
+			Name:                     debuggerContainerDefaultName,
+			Image:                    image,
+			ImagePullPolicy:          core_v1.PullIfNotPresent,
+			Stdin:                    true,
+			TTY:                      true,
+			TerminationMessagePolicy: core_v1.TerminationMessageFallbackToLogsOnError,
+			SecurityContext:          securityContextForProfile(profile),
+		},
 	}
+}
 
-	return nil
+// buildPodCopy returns a debug copy of pod with an additional debugger
+// container, mirroring `kubectl debug --copy-to`.
+func buildPodCopy(pod *core_v1.Pod, copyName, image string, profile Profile) *core_v1.Pod {
+	copyPod := pod.DeepCopy()
+	copyPod.ObjectMeta = metav1.ObjectMeta{
+		Name:      copyName,
+		Namespace: pod.Namespace,
+		Labels:    pod.Labels,
+	}
+	copyPod.Status = core_v1.PodStatus{}
+	copyPod.Spec.NodeName = ""
+	copyPod.Spec.EphemeralContainers = nil
+	copyPod.Spec.ShareProcessNamespace = boolPtr(true)
+	copyPod.Spec.Containers = append(copyPod.Spec.Containers, core_v1.Container{
+		Name:                     debuggerContainerDefaultName,
+		Image:                    image,
+		ImagePullPolicy:          core_v1.PullIfNotPresent,
+		Stdin:                    true,
+		TTY:                      true,
+		TerminationMessagePolicy: core_v1.TerminationMessageFallbackToLogsOnError,
+		SecurityContext:          securityContextForProfile(profile),
+	})
+	return copyPod
+}
+
+func boolPtr(b bool) *bool {
+	return &b
 }
 
 func (d *Debug) Debug() error {
@@ -0,0 +1,145 @@
+package debug
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// defaultRedactPattern matches common secret-shaped strings (API keys,
+// bearer tokens, password= assignments) so they don't end up verbatim in a
+// shared asciicast recording.
+const defaultRedactPattern = `(?i)(api[_-]?key|secret|password|token|authorization)\s*[:=]\s*\S+`
+
+const redactedPlaceholder = "[redacted]"
+
+// asciicastHeader is the first line of an asciicast v2 file.
+type asciicastHeader struct {
+	Version   int    `json:"version"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	Command   string `json:"command,omitempty"`
+}
+
+// Recorder writes an asciicast v2 (https://docs.asciinema.org/manual/asciicast/v2/)
+// recording of a debug session: an output event per write, plus a resize
+// event whenever the terminal changes size.
+type Recorder struct {
+	mu     sync.Mutex
+	file   io.WriteCloser
+	start  time.Time
+	redact *regexp.Regexp
+}
+
+// NewRecorder opens path and writes the asciicast header. redactPattern, if
+// non-empty, overrides defaultRedactPattern for lines that should be
+// scrubbed before they're persisted.
+func NewRecorder(path string, width, height int, redactPattern string) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording file %s: %w", path, err)
+	}
+
+	if redactPattern == "" {
+		redactPattern = defaultRedactPattern
+	}
+	re, err := regexp.Compile(redactPattern)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("invalid --record-redact pattern: %w", err)
+	}
+
+	start := time.Now()
+	header := asciicastHeader{Version: 2, Width: width, Height: height, Timestamp: start.Unix()}
+	if err := writeJSONLine(f, header); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &Recorder{file: f, start: start, redact: re}, nil
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// WriteOutput records an "o" (output) event for data, redacting any line
+// that matches the recorder's redact pattern first.
+func (r *Recorder) WriteOutput(data []byte) {
+	r.writeEvent("o", r.redactLines(string(data)))
+}
+
+// WriteResize records an "r" (resize) event.
+func (r *Recorder) WriteResize(cols, rows uint16) {
+	r.writeEvent("r", fmt.Sprintf("%dx%d", cols, rows))
+}
+
+func (r *Recorder) writeEvent(eventType, data string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	elapsed := time.Since(r.start).Seconds()
+	_ = writeJSONLine(r.file, []any{elapsed, eventType, data})
+}
+
+func (r *Recorder) redactLines(s string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i, line := range lines {
+		if !r.redact.MatchString(line) {
+			continue
+		}
+		body := strings.TrimRight(line, "\r\n")
+		lines[i] = redactedPlaceholder + line[len(body):]
+	}
+	return strings.Join(lines, "")
+}
+
+func writeJSONLine(w io.Writer, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// recordingWriter tees everything written to it into a Recorder as output
+// events, while still passing the bytes through to the real terminal.
+type recordingWriter struct {
+	io.Writer
+	rec *Recorder
+}
+
+func (w recordingWriter) Write(p []byte) (int, error) {
+	w.rec.WriteOutput(p)
+	return w.Writer.Write(p)
+}
+
+// watchResizes emits a resize event on rec every time the process receives
+// SIGWINCH, until ctx-like stop channel is closed.
+func watchResizes(rec *Recorder, getSize func() (cols, rows uint16, ok bool), stop <-chan struct{}) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGWINCH)
+	defer signal.Stop(ch)
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ch:
+			if cols, rows, ok := getSize(); ok {
+				rec.WriteResize(cols, rows)
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package debug
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRedactLines(t *testing.T) {
+	r := &Recorder{redact: regexp.MustCompile(defaultRedactPattern)}
+
+	tests := map[string]struct {
+		in   string
+		want string
+	}{
+		"password assignment is redacted": {
+			in:   "password=hunter2",
+			want: "[redacted]",
+		},
+		"api key assignment is redacted": {
+			in:   "API_KEY: abcdef123456",
+			want: "[redacted]",
+		},
+		"unrelated text is untouched": {
+			in:   "connecting to database...",
+			want: "connecting to database...",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := r.redactLines(tt.in); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
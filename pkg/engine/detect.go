@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	nais_io_v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DetectForApp looks up appName's Application resource in namespace and
+// resolves the Engine for its first Cloud SQL instance.
+func DetectForApp(ctx context.Context, c client.Client, appName, namespace string) (Engine, error) {
+	app := &nais_io_v1.Application{}
+	if err := c.Get(ctx, types.NamespacedName{Name: appName, Namespace: namespace}, app); err != nil {
+		return nil, fmt.Errorf("failed to get application %s/%s: %w", namespace, appName, err)
+	}
+
+	if app.Spec.GCP == nil || len(app.Spec.GCP.SqlInstances) == 0 {
+		return nil, fmt.Errorf("application %s/%s has no Cloud SQL instances configured", namespace, appName)
+	}
+
+	return Detect(app.Spec.GCP.SqlInstances[0].Type)
+}
+
+// RotatePasswordForApp detects the engine for appName and rotates its
+// database password, both in GCP and in the Kubernetes secret. It opens its
+// own Kubernetes client from kubeContext, so command packages using it don't
+// need to build one themselves.
+func RotatePasswordForApp(ctx context.Context, kubeContext, appName, namespace string) error {
+	c, err := clientFor(kubeContext)
+	if err != nil {
+		return err
+	}
+
+	eng, err := DetectForApp(ctx, c, appName, namespace)
+	if err != nil {
+		return err
+	}
+
+	return eng.RotatePassword(ctx, appName, kubeContext, namespace)
+}
+
+func clientFor(kubeContext string) (client.Client, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kube config: %w", err)
+	}
+
+	// client.New defaults an unset Scheme to clientgoscheme.Scheme, which
+	// has no knowledge of nais_io_v1.Application: build one explicitly so
+	// Get/List against Application resources don't fail with "no kind is
+	// registered for the type v1.Application".
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, fmt.Errorf("failed to build client scheme: %w", err)
+	}
+	if err := nais_io_v1.AddToScheme(scheme); err != nil {
+		return nil, fmt.Errorf("failed to build client scheme: %w", err)
+	}
+
+	return client.New(restConfig, client.Options{Scheme: scheme})
+}
@@ -0,0 +1,48 @@
+// Package engine abstracts the database-engine-specific parts of the
+// postgres/mysql/mssql commands (prepare, rotate, migrate) behind a common
+// interface, so the command layer can dispatch on the engine detected from
+// the NAIS Application spec instead of hard-coding PostgreSQL everywhere.
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Engine is implemented once per supported Cloud SQL engine.
+type Engine interface {
+	// Driver is the database/sql driver name used to connect, e.g.
+	// "cloudsqlpostgres".
+	Driver() string
+
+	// PrepareStatements translates spec into the DDL/DCL needed to grant it,
+	// in the engine's own dialect.
+	PrepareStatements(spec GrantSpec) []string
+
+	// RotatePassword rotates the database password for appName both in GCP
+	// and in the application's Kubernetes secret.
+	RotatePassword(ctx context.Context, appName, kubeContext, namespace string) error
+
+	// MigrationSourceConfig and MigrationTargetConfig return the
+	// engine-specific DMS connection profile for instanceName.
+	MigrationSourceConfig(instanceName string) any
+	MigrationTargetConfig(instanceName string) any
+}
+
+// Detect resolves the Engine implementation for the Cloud SQL database
+// version string found at spec.gcp.sqlInstances[0].type in a NAIS
+// Application manifest, e.g. "POSTGRES_15", "MYSQL_8_0",
+// "SQLSERVER_2019_STANDARD".
+func Detect(sqlInstanceType string) (Engine, error) {
+	switch {
+	case strings.HasPrefix(sqlInstanceType, "POSTGRES"):
+		return &postgresEngine{}, nil
+	case strings.HasPrefix(sqlInstanceType, "MYSQL"):
+		return &mysqlEngine{}, nil
+	case strings.HasPrefix(sqlInstanceType, "SQLSERVER"):
+		return &mssqlEngine{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported sql instance type %q", sqlInstanceType)
+	}
+}
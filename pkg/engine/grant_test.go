@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGrantFlag(t *testing.T) {
+	tests := map[string]struct {
+		value   string
+		want    GrantSpec
+		wantErr bool
+	}{
+		"defaults grantee": {
+			value: "schema=public,privs=SELECT,objects=TABLES",
+			want: GrantSpec{
+				Schemas:     []string{"public"},
+				Privileges:  []string{"SELECT"},
+				ObjectTypes: []string{"TABLES"},
+				Grantees:    []string{DefaultGrantee},
+			},
+		},
+		"accumulates repeated values under the last key": {
+			value: "schema=public,privs=SELECT,INSERT,objects=TABLES,to=myuser",
+			want: GrantSpec{
+				Schemas:     []string{"public"},
+				Privileges:  []string{"SELECT", "INSERT"},
+				ObjectTypes: []string{"TABLES"},
+				Grantees:    []string{"myuser"},
+			},
+		},
+		"unknown key is an error": {
+			value:   "schema=public,bogus=x",
+			wantErr: true,
+		},
+		"value with no preceding key is an error": {
+			value:   "SELECT",
+			wantErr: true,
+		},
+		"invalid privilege fails validation": {
+			value:   "schema=public,privs=DROP,objects=TABLES",
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseGrantFlag(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got spec %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/microsoft/go-mssqldb"
+)
+
+type mssqlEngine struct{}
+
+func (mssqlEngine) Driver() string { return "sqlserver" }
+
+// PrepareStatements translates the IAM-user default-privileges concept used
+// for PostgreSQL into MSSQL role membership: each requested privilege maps
+// to the closest built-in database role, and grantees are added as members
+// of that role rather than granted column/table privileges directly.
+func (mssqlEngine) PrepareStatements(spec GrantSpec) []string {
+	var stmts []string
+
+	for _, grantee := range spec.Grantees {
+		for _, role := range mssqlRolesFor(spec.Privileges) {
+			stmts = append(stmts, fmt.Sprintf("ALTER ROLE %s ADD MEMBER [%s];", role, grantee))
+		}
+	}
+
+	return stmts
+}
+
+// mssqlRoleOrder fixes the order roles are returned in, so dry-run output
+// and the statements generated from it are stable across runs instead of
+// depending on Go's randomized map iteration order.
+var mssqlRoleOrder = []string{"db_datareader", "db_datawriter"}
+
+func mssqlRolesFor(privileges []string) []string {
+	wanted := map[string]bool{}
+	for _, priv := range privileges {
+		switch strings.ToUpper(priv) {
+		case "SELECT":
+			wanted["db_datareader"] = true
+		case "INSERT", "UPDATE", "DELETE":
+			wanted["db_datawriter"] = true
+		case "ALL":
+			wanted["db_datareader"] = true
+			wanted["db_datawriter"] = true
+		}
+	}
+
+	var names []string
+	for _, role := range mssqlRoleOrder {
+		if wanted[role] {
+			names = append(names, role)
+		}
+	}
+	return names
+}
+
+func (mssqlEngine) RotatePassword(ctx context.Context, appName, kubeContext, namespace string) error {
+	return fmt.Errorf("password rotation for MSSQL instances is not yet implemented")
+}
+
+func (mssqlEngine) MigrationSourceConfig(instanceName string) any {
+	return mssqlDMSConnectionProfile{InstanceName: instanceName, Engine: "SQLSERVER"}
+}
+
+func (mssqlEngine) MigrationTargetConfig(instanceName string) any {
+	return mssqlDMSConnectionProfile{InstanceName: instanceName, Engine: "SQLSERVER"}
+}
+
+type mssqlDMSConnectionProfile struct {
+	InstanceName string
+	Engine       string
+}
+
+// SplitBatches splits a sqlcmd-style script on lines containing only `GO`,
+// mirroring how MSSQL tooling (and the Kanister MSSQL blueprint) batches
+// statements for execution.
+func SplitBatches(script string) []string {
+	var batches []string
+	var current []string
+
+	for _, line := range strings.Split(script, "\n") {
+		if strings.EqualFold(strings.TrimSpace(line), "GO") {
+			if batch := strings.TrimSpace(strings.Join(current, "\n")); batch != "" {
+				batches = append(batches, batch)
+			}
+			current = current[:0]
+			continue
+		}
+		current = append(current, line)
+	}
+
+	if batch := strings.TrimSpace(strings.Join(current, "\n")); batch != "" {
+		batches = append(batches, batch)
+	}
+
+	return batches
+}
+
+// sqlExecer is satisfied by both *sql.DB and *sql.Tx, letting ExecBatches
+// run against a transaction when one is already open.
+type sqlExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// ExecBatches runs each of SplitBatches(script) in sequence against db,
+// stopping at the first error.
+func ExecBatches(ctx context.Context, db sqlExecer, script string) error {
+	for _, batch := range SplitBatches(script) {
+		if _, err := db.ExecContext(ctx, batch); err != nil {
+			return fmt.Errorf("batch failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// ExecBatch joins stmts into a single sqlcmd-style script, one ALTER ROLE
+// statement per GO batch, and runs it through ExecBatches against tx. It
+// lets applyGrants execute mssqlEngine's statements sqlcmd-style instead of
+// one at a time, while staying inside the caller's transaction.
+func (mssqlEngine) ExecBatch(ctx context.Context, tx *sql.Tx, stmts []string) error {
+	return ExecBatches(ctx, tx, strings.Join(stmts, "\nGO\n"))
+}
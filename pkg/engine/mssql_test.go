@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMssqlRolesFor(t *testing.T) {
+	tests := map[string]struct {
+		privileges []string
+		want       []string
+	}{
+		"select maps to db_datareader": {
+			privileges: []string{"SELECT"},
+			want:       []string{"db_datareader"},
+		},
+		"insert/update/delete map to db_datawriter": {
+			privileges: []string{"INSERT", "UPDATE", "DELETE"},
+			want:       []string{"db_datawriter"},
+		},
+		"all maps to both roles, in a fixed order": {
+			privileges: []string{"ALL"},
+			want:       []string{"db_datareader", "db_datawriter"},
+		},
+		"order is stable regardless of input order": {
+			privileges: []string{"DELETE", "SELECT"},
+			want:       []string{"db_datareader", "db_datawriter"},
+		},
+		"unknown privileges map to no role": {
+			privileges: []string{"TRUNCATE"},
+			want:       nil,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				got := mssqlRolesFor(tt.privileges)
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("got %#v, want %#v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitBatches(t *testing.T) {
+	tests := map[string]struct {
+		script string
+		want   []string
+	}{
+		"single batch, no GO": {
+			script: "ALTER ROLE db_datareader ADD MEMBER [iamuser];",
+			want:   []string{"ALTER ROLE db_datareader ADD MEMBER [iamuser];"},
+		},
+		"multiple batches split on GO": {
+			script: "ALTER ROLE db_datareader ADD MEMBER [iamuser];\nGO\nALTER ROLE db_datawriter ADD MEMBER [iamuser];\nGO\n",
+			want: []string{
+				"ALTER ROLE db_datareader ADD MEMBER [iamuser];",
+				"ALTER ROLE db_datawriter ADD MEMBER [iamuser];",
+			},
+		},
+		"GO is case-insensitive and trims whitespace": {
+			script: "  select 1;\n go \nselect 2;",
+			want:   []string{"select 1;", "select 2;"},
+		},
+		"empty script yields no batches": {
+			script: "",
+			want:   nil,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := SplitBatches(tt.script)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
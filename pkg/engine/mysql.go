@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type mysqlEngine struct{}
+
+func (mysqlEngine) Driver() string { return "mysql" }
+
+// PrepareStatements grants MySQL-style privileges directly on the schema,
+// since MySQL has no ALTER DEFAULT PRIVILEGES equivalent for existing
+// objects created by other users.
+func (mysqlEngine) PrepareStatements(spec GrantSpec) []string {
+	privs := strings.Join(spec.Privileges, ", ")
+	var stmts []string
+
+	for _, schema := range spec.Schemas {
+		for _, grantee := range spec.Grantees {
+			stmts = append(stmts,
+				fmt.Sprintf("GRANT %s ON `%s`.* TO '%s'@'%%';", privs, schema, grantee),
+				"FLUSH PRIVILEGES;",
+			)
+		}
+	}
+
+	return stmts
+}
+
+func (mysqlEngine) RotatePassword(ctx context.Context, appName, kubeContext, namespace string) error {
+	return fmt.Errorf("password rotation for MySQL instances is not yet implemented")
+}
+
+func (mysqlEngine) MigrationSourceConfig(instanceName string) any {
+	return mysqlDMSConnectionProfile{InstanceName: instanceName, Engine: "MYSQL"}
+}
+
+func (mysqlEngine) MigrationTargetConfig(instanceName string) any {
+	return mysqlDMSConnectionProfile{InstanceName: instanceName, Engine: "MYSQL"}
+}
+
+type mysqlDMSConnectionProfile struct {
+	InstanceName string
+	Engine       string
+}
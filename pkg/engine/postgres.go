@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/nais/cli/pkg/postgres"
+)
+
+type postgresEngine struct{}
+
+func (postgresEngine) Driver() string { return "cloudsqlpostgres" }
+
+func (postgresEngine) PrepareStatements(spec GrantSpec) []string {
+	// held is nil here, so postgresPrepareStatements never calls it and
+	// never returns an error.
+	stmts, _ := postgresPrepareStatements(spec, nil)
+	return stmts
+}
+
+// PrepareStatementsWithTx is the postgres-specific extension of
+// PrepareStatements that callers can reach via a type assertion on Engine:
+// it queries information_schema.role_table_grants through tx to skip
+// statements that wouldn't change anything, making repeated `prepare` runs
+// idempotent for table grants.
+func (postgresEngine) PrepareStatementsWithTx(ctx context.Context, tx *sql.Tx, spec GrantSpec) ([]string, error) {
+	held := func(schema, grantee, objectType, priv string) (bool, error) {
+		if !strings.EqualFold(objectType, "TABLES") || strings.EqualFold(priv, "ALL") {
+			return false, nil
+		}
+		// Compare the number of distinct tables grantee holds priv on
+		// against the total number of tables in the schema: a row in
+		// role_table_grants only proves the privilege is held on *a*
+		// table, not on every table, so a plain existence check would
+		// wrongly skip the re-grant after a new table is added to the
+		// schema. count(distinct table_name) guards against the same
+		// table appearing more than once (e.g. granted by two different
+		// grantors), which would otherwise let granted reach total while
+		// a different table in the schema has no grant at all.
+		const query = `
+			select
+				(select count(distinct table_name) from information_schema.role_table_grants
+					where table_schema = $1 and grantee = $2 and privilege_type = $3),
+				(select count(*) from information_schema.tables
+					where table_schema = $1 and table_type = 'BASE TABLE')`
+		var granted, total int
+		if err := tx.QueryRowContext(ctx, query, schema, grantee, strings.ToUpper(priv)).Scan(&granted, &total); err != nil {
+			return false, err
+		}
+		return total > 0 && granted >= total, nil
+	}
+
+	return postgresPrepareStatements(spec, held)
+}
+
+func postgresPrepareStatements(spec GrantSpec, held HoldChecker) ([]string, error) {
+	privs := strings.Join(spec.Privileges, ", ")
+	var stmts []string
+
+	for _, schema := range spec.Schemas {
+		for _, objectType := range spec.ObjectTypes {
+			for _, grantee := range spec.Grantees {
+				if held != nil {
+					allHeld, err := allPrivilegesHeld(held, schema, grantee, objectType, spec.Privileges)
+					if err != nil {
+						return nil, fmt.Errorf("failed to check existing grants for %s.%s: %w", schema, grantee, err)
+					}
+					if allHeld {
+						continue
+					}
+				}
+				stmts = append(stmts,
+					fmt.Sprintf("alter default privileges in schema %s grant %s on %s to %s;", schema, privs, strings.ToLower(objectType), grantee),
+					fmt.Sprintf("grant %s on all %s in schema %s to %s;", privs, strings.ToLower(objectType), schema, grantee),
+				)
+			}
+		}
+	}
+
+	return stmts, nil
+}
+
+func allPrivilegesHeld(held HoldChecker, schema, grantee, objectType string, privileges []string) (bool, error) {
+	for _, priv := range privileges {
+		ok, err := held(schema, grantee, objectType, priv)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (postgresEngine) RotatePassword(ctx context.Context, appName, kubeContext, namespace string) error {
+	return postgres.RotatePassword(ctx, appName, kubeContext, namespace)
+}
+
+func (postgresEngine) MigrationSourceConfig(instanceName string) any {
+	return postgresDMSConnectionProfile{InstanceName: instanceName, Engine: "POSTGRESQL"}
+}
+
+func (postgresEngine) MigrationTargetConfig(instanceName string) any {
+	return postgresDMSConnectionProfile{InstanceName: instanceName, Engine: "POSTGRESQL"}
+}
+
+// postgresDMSConnectionProfile mirrors the fields DMS needs to address a
+// CloudSQL-for-PostgreSQL instance as a migration source or target.
+type postgresDMSConnectionProfile struct {
+	InstanceName string
+	Engine       string
+}
@@ -3,11 +3,16 @@ package migrate
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
 	"github.com/pterm/pterm"
+	datamigration "google.golang.org/api/datamigration/v1"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/util/rand"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"time"
 )
 
 const SetupSuccessMessage = `
@@ -106,12 +111,15 @@ This will create a new database instance and start replication of data from the
 	//if err != nil {
 	//	return err
 	//}
+	jobName := "jobName"
+
+	migrationJobPath := fmt.Sprintf("projects/%s/locations/europe-north1/migrationJobs/%s-%s", gcpProjectId, sourceInstanceName, targetInstanceName)
 
 	ptermFailed := false
 	if wait {
-		err = m.doSpinner()
+		err = m.watchSetup(ctx, migrationJobPath, jobName)
 		if err != nil {
-			fmt.Println("Failed to start spinner:", err)
+			fmt.Println("Failed to watch migration setup:", err)
 			ptermFailed = true
 		}
 	}
@@ -119,27 +127,46 @@ This will create a new database instance and start replication of data from the
 	if !wait || ptermFailed {
 		cloudConsoleUrl := fmt.Sprintf("https://console.cloud.google.com/dbmigration/migrations/locations/europe-north1/instances/%s-%s?project=%s", m.cfg.Source.InstanceName, m.cfg.Target.InstanceName, gcpProjectId)
 		label := m.kubectlLabelSelector(CommandSetup)
-		fmt.Printf(SetupSuccessMessage, label, m.cfg.Namespace, "jobName", m.cfg.Namespace, cloudConsoleUrl, m.cfg.AppName, m.cfg.Namespace, m.cfg.Target.InstanceName)
+		fmt.Printf(SetupSuccessMessage, label, m.cfg.Namespace, jobName, m.cfg.Namespace, cloudConsoleUrl, m.cfg.AppName, m.cfg.Namespace, m.cfg.Target.InstanceName)
 	}
 	return nil
 }
 
-func (m *Migrator) doSpinner() error {
-	multi := pterm.DefaultMultiPrinter
+// phaseText renders a DMS MigrationJob's state/phase as the text shown next
+// to the setup spinner, e.g. "RUNNING/FULL_DUMP".
+func phaseText(job *datamigration.MigrationJob) string {
+	if job.Phase == "" || job.Phase == "PHASE_UNSPECIFIED" {
+		return job.State
+	}
+	return fmt.Sprintf("%s/%s", job.State, job.Phase)
+}
 
-	logStatements := make(chan string)
+func isTerminalPhase(job *datamigration.MigrationJob) bool {
+	switch job.State {
+	case "FAILED", "COMPLETED", "READY_TO_PROMOTE":
+		return true
+	default:
+		return false
+	}
+}
 
-	go func() {
-		for i := 0; i < 50; i++ {
-			delay := rand.IntnRange(5, 10)
-			time.Sleep(time.Second * time.Duration(delay))
-			logStatements <- fmt.Sprintf("Migration setup is still running, waited %d seconds", delay)
-		}
-		close(logStatements)
-	}()
+// watchSetup drives a pterm.DefaultMultiPrinter with three concurrently
+// updated regions: a spinner tracking DMS MigrationJob phase transitions, a
+// live tail of the setup job's pod logs, and a progress bar derived from the
+// job's DumpProgress. It polls the DMS API with exponential backoff (5s,
+// capped at 60s) until the job reaches a terminal phase, ctx is cancelled,
+// or the user interrupts with Ctrl-C.
+func (m *Migrator) watchSetup(ctx context.Context, migrationJobPath, jobName string) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	defer cancel()
+
+	dmsService, err := datamigration.NewService(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create Database Migration Service client: %w", err)
+	}
+
+	multi := pterm.DefaultMultiPrinter
 
-	logOutput := pterm.DefaultLogger.WithWriter(multi.NewWriter())
-	logOutput.Info("Migration setup has started")
 	setupSpinner, err := pterm.DefaultSpinner.
 		WithWriter(multi.NewWriter()).
 		WithSequence("  .  ", " ... ", ".....", " ... ").
@@ -148,25 +175,98 @@ func (m *Migrator) doSpinner() error {
 		return fmt.Errorf("failed to start spinner: %w", err)
 	}
 
-	_, err = multi.Start()
+	logOutput := pterm.DefaultLogger.WithWriter(multi.NewWriter())
+	progressBar, err := pterm.DefaultProgressbar.
+		WithWriter(multi.NewWriter()).
+		WithTotal(100).
+		WithTitle("Full dump progress").
+		Start()
 	if err != nil {
+		return fmt.Errorf("failed to start progress bar: %w", err)
+	}
+
+	if _, err := multi.Start(); err != nil {
 		return fmt.Errorf("failed to start multi printer: %w", err)
 	}
 	defer multi.Stop()
 
-	prevLog := ""
-	for logMsg := range logStatements {
-		switch rand.IntnRange(1, 3) {
-		case 1:
-			logOutput.Warn(prevLog)
-		case 2:
-			logOutput.Error(prevLog)
-		default:
-			logOutput.Info(prevLog)
+	go m.tailJobLogs(ctx, jobName, logOutput)
+
+	backoff := 5 * time.Second
+	const maxBackoff = 60 * time.Second
+
+	for {
+		job, err := dmsService.Projects.Locations.MigrationJobs.Get(migrationJobPath).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("failed to get migration job status: %w", err)
+		}
+
+		setupSpinner.UpdateText(phaseText(job))
+		if job.DumpProgress != nil {
+			progressBar.Current = int(job.DumpProgress.PercentComplete)
+		}
+
+		if isTerminalPhase(job) {
+			if job.State == "FAILED" {
+				msg := "unknown error"
+				if job.Error != nil {
+					msg = job.Error.Message
+				}
+				setupSpinner.Fail("Migration setup failed: " + msg)
+				return fmt.Errorf("migration job failed: %s", msg)
+			}
+			setupSpinner.Success("Migration setup complete")
+			progressBar.Current = progressBar.Total
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			setupSpinner.Stop()
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// tailJobLogs streams the setup job's pod logs to logOutput until the log
+// stream ends or ctx is cancelled. The pod is looked up once: if it does not
+// exist at that point, a warning is logged and no logs are tailed. Errors are
+// logged rather than returned, since log tailing is best-effort alongside the
+// DMS phase watcher.
+func (m *Migrator) tailJobLogs(ctx context.Context, jobName string, logOutput *pterm.Logger) {
+	pods, err := m.clientset.CoreV1().Pods(m.cfg.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("job-name=%s", jobName),
+	})
+	if err != nil || len(pods.Items) == 0 {
+		logOutput.Warn("no setup job pod found to tail logs from yet")
+		return
+	}
+
+	podName := pods.Items[0].Name
+	stream, err := m.clientset.CoreV1().Pods(m.cfg.Namespace).GetLogs(podName, &v1.PodLogOptions{Follow: true}).Stream(ctx)
+	if err != nil {
+		logOutput.Warn(fmt.Sprintf("failed to stream logs from %s: %v", podName, err))
+		return
+	}
+	defer stream.Close()
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := stream.Read(buf)
+		if n > 0 {
+			logOutput.Info(string(buf[:n]))
+		}
+		if err != nil {
+			if err != io.EOF && ctx.Err() == nil {
+				logOutput.Warn(fmt.Sprintf("log stream from %s ended: %v", podName, err))
+			}
+			return
 		}
-		setupSpinner.UpdateText(logMsg)
-		prevLog = logMsg
 	}
-	setupSpinner.Success("Migration setup complete")
-	return nil
 }
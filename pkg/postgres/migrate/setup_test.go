@@ -0,0 +1,57 @@
+package migrate
+
+import (
+	"testing"
+
+	datamigration "google.golang.org/api/datamigration/v1"
+)
+
+func TestPhaseText(t *testing.T) {
+	tests := map[string]struct {
+		job  *datamigration.MigrationJob
+		want string
+	}{
+		"no phase falls back to state": {
+			job:  &datamigration.MigrationJob{State: "RUNNING"},
+			want: "RUNNING",
+		},
+		"unspecified phase falls back to state": {
+			job:  &datamigration.MigrationJob{State: "RUNNING", Phase: "PHASE_UNSPECIFIED"},
+			want: "RUNNING",
+		},
+		"state and phase are combined": {
+			job:  &datamigration.MigrationJob{State: "RUNNING", Phase: "FULL_DUMP"},
+			want: "RUNNING/FULL_DUMP",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := phaseText(tt.job); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsTerminalPhase(t *testing.T) {
+	tests := map[string]struct {
+		state string
+		want  bool
+	}{
+		"failed is terminal":           {state: "FAILED", want: true},
+		"completed is terminal":        {state: "COMPLETED", want: true},
+		"ready to promote is terminal": {state: "READY_TO_PROMOTE", want: true},
+		"running is not terminal":      {state: "RUNNING", want: false},
+		"unspecified is not terminal":  {state: "STATE_UNSPECIFIED", want: false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			job := &datamigration.MigrationJob{State: tt.state}
+			if got := isTerminalPhase(job); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}